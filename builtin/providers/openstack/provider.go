@@ -0,0 +1,16 @@
+package openstack
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Provider returns a terraform.ResourceProvider for OpenStack.
+func Provider() terraform.ResourceProvider {
+	return &schema.Provider{
+		ResourcesMap: map[string]*schema.Resource{
+			"openstack_compute_instance_v2":    resourceComputeInstanceV2(),
+			"openstack_compute_servergroup_v2": resourceComputeServerGroupV2(),
+		},
+	}
+}
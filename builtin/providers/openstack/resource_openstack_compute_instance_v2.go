@@ -3,18 +3,30 @@ package openstack
 import (
 	"bytes"
 	"crypto/sha1"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math/rand"
+	"sort"
 	"time"
 
 	"github.com/hashicorp/terraform/helper/hashcode"
 	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/rackspace/gophercloud"
+	"github.com/rackspace/gophercloud/openstack/blockstorage/v1/snapshots"
+	"github.com/rackspace/gophercloud/openstack/blockstorage/v1/volumes"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/availabilityzones"
 	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/bootfromvolume"
 	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/keypairs"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/pauseunpause"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/rescueunrescue"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/schedulerhints"
 	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/secgroups"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/startstop"
+	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/suspendresume"
 	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/volumeattach"
 	"github.com/rackspace/gophercloud/openstack/compute/v2/flavors"
 	"github.com/rackspace/gophercloud/openstack/compute/v2/images"
@@ -25,6 +37,69 @@ import (
 	"github.com/rackspace/gophercloud/pagination"
 )
 
+// retryableNovaStatusCodes are the HTTP status codes Nova is known to
+// return transiently while a server is mid-transition (e.g. attaching a
+// volume while still leaving BUILD).
+var retryableNovaStatusCodes = map[int]bool{
+	409: true,
+	500: true,
+	502: true,
+	503: true,
+	504: true,
+}
+
+func isRetryableNovaError(err error) bool {
+	errCode, ok := err.(*gophercloud.UnexpectedResponseCodeError)
+	if !ok {
+		return false
+	}
+	return retryableNovaStatusCodes[errCode.Actual]
+}
+
+// resourceInstanceTimeoutV2 reads the configured timeouts block, falling
+// back to 10 minutes if the user didn't set one.
+func resourceInstanceTimeoutV2(d *schema.ResourceData, key string) time.Duration {
+	if timeouts := d.Get("timeouts").([]interface{}); len(timeouts) > 0 {
+		if raw, ok := timeouts[0].(map[string]interface{})[key].(string); ok && raw != "" {
+			if dur, err := time.ParseDuration(raw); err == nil {
+				return dur
+			}
+		}
+	}
+	return 10 * time.Minute
+}
+
+// retryNovaCall retries f with exponential backoff and jitter as long as
+// it keeps returning a retryable gophercloud error, giving up once
+// timeout has elapsed.
+func retryNovaCall(timeout time.Duration, f func() error) error {
+	deadline := time.Now().Add(timeout)
+	backoff := 1 * time.Second
+
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+		if !isRetryableNovaError(err) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Gave up retrying after %s: %s", timeout, err)
+		}
+
+		jitter := time.Duration(rand.Int63n(int64(backoff)))
+		sleep := backoff/2 + jitter
+		log.Printf("[DEBUG] Retryable error from Nova (attempt %d): %s. Retrying in %s", attempt+1, err, sleep)
+		time.Sleep(sleep)
+
+		backoff *= 2
+		if backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
 func resourceComputeInstanceV2() *schema.Resource {
 	return &schema.Resource{
 		Create: resourceComputeInstanceV2Create,
@@ -72,7 +147,34 @@ func resourceComputeInstanceV2() *schema.Resource {
 				Computed:    true,
 				DefaultFunc: envDefaultFunc("OS_FLAVOR_NAME"),
 			},
+			"flavor_properties": &schema.Schema{
+				Type:     schema.TypeMap,
+				Optional: true,
+				ForceNew: false,
+			},
+			"flavor_min_vcpus": &schema.Schema{
+				Type:     schema.TypeInt,
+				Optional: true,
+				ForceNew: false,
+			},
+			"flavor_min_ram": &schema.Schema{
+				Type:     schema.TypeInt,
+				Optional: true,
+				ForceNew: false,
+			},
+			"flavor_min_disk": &schema.Schema{
+				Type:     schema.TypeInt,
+				Optional: true,
+				ForceNew: false,
+			},
 			"floating_ip": &schema.Schema{
+				Type:       schema.TypeString,
+				Optional:   true,
+				Computed:   true,
+				ForceNew:   false,
+				Deprecated: "Use the network.floating_ip attribute instead",
+			},
+			"floating_ip_pool": &schema.Schema{
 				Type:     schema.TypeString,
 				Optional: true,
 				ForceNew: false,
@@ -92,6 +194,23 @@ func resourceComputeInstanceV2() *schema.Resource {
 					}
 				},
 			},
+			"personality": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"path": &schema.Schema{
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"content": &schema.Schema{
+							Type:     schema.TypeString,
+							Required: true,
+						},
+					},
+				},
+			},
 			"security_groups": &schema.Schema{
 				Type:     schema.TypeList,
 				Optional: true,
@@ -103,6 +222,12 @@ func resourceComputeInstanceV2() *schema.Resource {
 				Optional: true,
 				ForceNew: true,
 			},
+			"availability_zones": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
 			"network": &schema.Schema{
 				Type:     schema.TypeList,
 				Optional: true,
@@ -121,6 +246,11 @@ func resourceComputeInstanceV2() *schema.Resource {
 							Type:     schema.TypeString,
 							Optional: true,
 						},
+						"floating_ip": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+							ForceNew: false,
+						},
 					},
 				},
 			},
@@ -129,6 +259,54 @@ func resourceComputeInstanceV2() *schema.Resource {
 				Optional: true,
 				ForceNew: false,
 			},
+			"scheduler_hints": &schema.Schema{
+				Type:     schema.TypeSet,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"group": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+							ForceNew: true,
+						},
+						"different_host": &schema.Schema{
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"same_host": &schema.Schema{
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"query": &schema.Schema{
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"target_cell": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+							ForceNew: true,
+						},
+						"build_near_host_ip": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+							ForceNew: true,
+						},
+						"cidr": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+							ForceNew: true,
+						},
+					},
+				},
+				Set: resourceComputeSchedulerHintsHash,
+			},
 			"config_drive": &schema.Schema{
 				Type:     schema.TypeBool,
 				Optional: true,
@@ -156,6 +334,12 @@ func resourceComputeInstanceV2() *schema.Resource {
 				Optional: true,
 				ForceNew: true,
 			},
+			"power_state": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ForceNew: false,
+			},
 			"block_device": &schema.Schema{
 				Type:     schema.TypeList,
 				Optional: true,
@@ -185,6 +369,42 @@ func resourceComputeInstanceV2() *schema.Resource {
 					},
 				},
 			},
+			"use_blockstorage_volume": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+			},
+			"volume_size": &schema.Schema{
+				Type:     schema.TypeInt,
+				Optional: true,
+				ForceNew: true,
+			},
+			"volume_name": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+			},
+			"volume_type": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+			"volume_availability_zone": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+			"boot_volume_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"delete_on_termination": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+				ForceNew: true,
+			},
 			"volume": &schema.Schema{
 				Type:     schema.TypeSet,
 				Optional: true,
@@ -204,10 +424,38 @@ func resourceComputeInstanceV2() *schema.Resource {
 							Optional: true,
 							Computed: true,
 						},
+						"snapshot_before_detach": &schema.Schema{
+							Type:     schema.TypeBool,
+							Optional: true,
+						},
 					},
 				},
 				Set: resourceComputeVolumeAttachmentHash,
 			},
+			"timeouts": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"create": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "10m",
+						},
+						"update": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "10m",
+						},
+						"delete": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "10m",
+						},
+					},
+				},
+			},
 		},
 	}
 }
@@ -231,17 +479,59 @@ func resourceComputeInstanceV2Create(d *schema.ResourceData, meta interface{}) e
 		return err
 	}
 
+	personality, err := resourceInstancePersonalityV2(d)
+	if err != nil {
+		return err
+	}
+
+	useBlockstorageVolume := d.Get("use_blockstorage_volume").(bool)
+
+	var blockClient *gophercloud.ServiceClient
+	if useBlockstorageVolume {
+		blockClient, err = config.blockStorageV1Client(d.Get("region").(string))
+		if err != nil {
+			return fmt.Errorf("Error creating OpenStack block storage client: %s", err)
+		}
+	}
+
+	availabilityZone := d.Get("availability_zone").(string)
+	if rawZones := d.Get("availability_zones").([]interface{}); len(rawZones) > 0 {
+		zones := make([]string, len(rawZones))
+		for i, z := range rawZones {
+			zones[i] = z.(string)
+		}
+
+		pickedZone, err := pickAvailabilityZone(computeClient, blockClient, zones, useBlockstorageVolume)
+		if err != nil {
+			return fmt.Errorf("Error picking an availability zone: %s", err)
+		}
+		availabilityZone = pickedZone
+	}
+
+	var bootVolume *volumes.Volume
+	if useBlockstorageVolume {
+		bootVolume, err = resourceInstanceBootVolumeV2(blockClient, d, imageId, availabilityZone)
+		if err != nil {
+			return fmt.Errorf("Error creating OpenStack root volume: %s", err)
+		}
+
+		// Nova doesn't need the image once the root volume has been
+		// created from it.
+		imageId = ""
+	}
+
 	createOpts = &servers.CreateOpts{
 		Name:             d.Get("name").(string),
 		ImageRef:         imageId,
 		FlavorRef:        flavorId,
 		SecurityGroups:   resourceInstanceSecGroupsV2(d),
-		AvailabilityZone: d.Get("availability_zone").(string),
+		AvailabilityZone: availabilityZone,
 		Networks:         resourceInstanceNetworksV2(d),
 		Metadata:         resourceInstanceMetadataV2(d),
 		ConfigDrive:      d.Get("config_drive").(bool),
 		AdminPass:        d.Get("admin_pass").(string),
 		UserData:         []byte(d.Get("user_data").(string)),
+		Personality:      personality,
 	}
 
 	if keyName, ok := d.Get("key_pair").(string); ok && keyName != "" {
@@ -259,8 +549,36 @@ func resourceComputeInstanceV2Create(d *schema.ResourceData, meta interface{}) e
 		}
 	}
 
+	if schedulerHintsRaw := d.Get("scheduler_hints").(*schema.Set).List(); len(schedulerHintsRaw) > 0 {
+		schedulerHints := resourceInstanceSchedulerHintsV2(schedulerHintsRaw[0].(map[string]interface{}))
+		createOpts = &schedulerhints.CreateOptsExt{
+			createOpts,
+			schedulerHints,
+		}
+	}
+
+	if bootVolume != nil {
+		createOpts = &bootfromvolume.CreateOptsExt{
+			createOpts,
+			[]bootfromvolume.BlockDevice{
+				bootfromvolume.BlockDevice{
+					UUID:                bootVolume.ID,
+					SourceType:          bootfromvolume.SourceType("volume"),
+					DestinationType:     "volume",
+					BootIndex:           0,
+					DeleteOnTermination: d.Get("delete_on_termination").(bool),
+				},
+			},
+		}
+	}
+
 	log.Printf("[DEBUG] Create Options: %#v", createOpts)
-	server, err := servers.Create(computeClient, createOpts).Extract()
+	var server *servers.Server
+	err = retryNovaCall(resourceInstanceTimeoutV2(d, "create"), func() error {
+		var createErr error
+		server, createErr = servers.Create(computeClient, createOpts).Extract()
+		return createErr
+	})
 	if err != nil {
 		return fmt.Errorf("Error creating OpenStack server: %s", err)
 	}
@@ -269,6 +587,10 @@ func resourceComputeInstanceV2Create(d *schema.ResourceData, meta interface{}) e
 	// Store the ID now
 	d.SetId(server.ID)
 
+	if bootVolume != nil {
+		d.Set("boot_volume_id", bootVolume.ID)
+	}
+
 	// Wait for the instance to become running so we can get some attributes
 	// that aren't available until later.
 	log.Printf(
@@ -291,6 +613,7 @@ func resourceComputeInstanceV2Create(d *schema.ResourceData, meta interface{}) e
 			server.ID, err)
 	}
 	floatingIP := d.Get("floating_ip").(string)
+	floatingIPPool := d.Get("floating_ip_pool").(string)
 	if floatingIP != "" {
 		networkingClient, err := config.networkingV2Client(d.Get("region").(string))
 		if err != nil {
@@ -301,10 +624,34 @@ func resourceComputeInstanceV2Create(d *schema.ResourceData, meta interface{}) e
 		if err != nil {
 			return fmt.Errorf("Error listing OpenStack floating IPs: %s", err)
 		}
-		err = assignFloatingIP(networkingClient, extractFloatingIPFromIP(allFloatingIPs, floatingIP), server.ID)
+		fip := extractFloatingIPFromIP(allFloatingIPs, floatingIP)
+		err = retryNovaCall(resourceInstanceTimeoutV2(d, "create"), func() error {
+			return assignFloatingIP(networkingClient, fip, server.ID)
+		})
+		if err != nil {
+			return fmt.Errorf("Error assigning floating IP to OpenStack compute instance: %s", err)
+		}
+	} else if floatingIPPool != "" {
+		networkingClient, err := config.networkingV2Client(d.Get("region").(string))
 		if err != nil {
-			fmt.Errorf("Error assigning floating IP to OpenStack compute instance: %s", err)
+			return fmt.Errorf("Error creating OpenStack compute client: %s", err)
 		}
+
+		newFloatingIP, err := allocateFloatingIPFromPool(networkingClient, floatingIPPool)
+		if err != nil {
+			return fmt.Errorf("Error allocating floating IP from pool (%s): %s", floatingIPPool, err)
+		}
+		err = retryNovaCall(resourceInstanceTimeoutV2(d, "create"), func() error {
+			return assignFloatingIP(networkingClient, newFloatingIP, server.ID)
+		})
+		if err != nil {
+			return fmt.Errorf("Error assigning floating IP to OpenStack compute instance: %s", err)
+		}
+		d.Set("floating_ip", newFloatingIP.FloatingIP)
+	}
+
+	if err := assignFloatingIPsToNetworksV2(d, config, server.ID); err != nil {
+		return err
 	}
 
 	// were volume attachments specified?
@@ -314,7 +661,7 @@ func resourceComputeInstanceV2Create(d *schema.ResourceData, meta interface{}) e
 			if blockClient, err := config.blockStorageV1Client(d.Get("region").(string)); err != nil {
 				return fmt.Errorf("Error creating OpenStack block storage client: %s", err)
 			} else {
-				if err := attachVolumesToInstance(computeClient, blockClient, d.Id(), vols); err != nil {
+				if err := attachVolumesToInstance(computeClient, blockClient, d.Id(), vols, resourceInstanceTimeoutV2(d, "create")); err != nil {
 					return err
 				}
 			}
@@ -341,6 +688,7 @@ func resourceComputeInstanceV2Read(d *schema.ResourceData, meta interface{}) err
 	d.Set("name", server.Name)
 	d.Set("access_ip_v4", server.AccessIPv4)
 	d.Set("access_ip_v6", server.AccessIPv6)
+	d.Set("power_state", powerStateFromServerStatus(server.Status))
 
 	hostv4 := server.AccessIPv4
 	if hostv4 == "" {
@@ -441,11 +789,23 @@ func resourceComputeInstanceV2Read(d *schema.ResourceData, meta interface{}) err
 	}
 	d.Set("image_id", imageId)
 
-	image, err := images.Get(computeClient, imageId).Extract()
+	var image *images.Image
+	err = retryGlanceCall(func() error {
+		var getErr error
+		image, getErr = images.Get(computeClient, imageId).Extract()
+		return getErr
+	})
 	if err != nil {
-		return err
+		errCode, ok := err.(*gophercloud.UnexpectedResponseCodeError)
+		if !ok || errCode.Actual != 404 {
+			return err
+		}
+		// The source image has since been deleted from Glance; long-lived
+		// instances routinely outlive it, so don't fail the refresh.
+		d.Set("image_name", glanceImageNotFound)
+	} else {
+		d.Set("image_name", image.Name)
 	}
-	d.Set("image_name", image.Name)
 
 	// volume attachments
 	vas, err := getVolumeAttachments(computeClient, d.Id())
@@ -519,11 +879,14 @@ func resourceComputeInstanceV2Update(d *schema.ResourceData, meta interface{}) e
 		log.Printf("[DEBUG] Security groups to remove: %v", secgroupsToRemove)
 
 		for _, g := range secgroupsToAdd.List() {
-			err := secgroups.AddServerToGroup(computeClient, d.Id(), g.(string)).ExtractErr()
+			groupName := g.(string)
+			err := retryNovaCall(resourceInstanceTimeoutV2(d, "update"), func() error {
+				return secgroups.AddServerToGroup(computeClient, d.Id(), groupName).ExtractErr()
+			})
 			if err != nil {
 				return fmt.Errorf("Error adding security group to OpenStack server (%s): %s", d.Id(), err)
 			}
-			log.Printf("[DEBUG] Added security group (%s) to instance (%s)", g.(string), d.Id())
+			log.Printf("[DEBUG] Added security group (%s) to instance (%s)", groupName, d.Id())
 		}
 
 		for _, g := range secgroupsToRemove.List() {
@@ -565,9 +928,12 @@ func resourceComputeInstanceV2Update(d *schema.ResourceData, meta interface{}) e
 			if err != nil {
 				return fmt.Errorf("Error listing OpenStack floating IPs: %s", err)
 			}
-			err = assignFloatingIP(networkingClient, extractFloatingIPFromIP(allFloatingIPs, floatingIP), d.Id())
+			fip := extractFloatingIPFromIP(allFloatingIPs, floatingIP)
+			err = retryNovaCall(resourceInstanceTimeoutV2(d, "update"), func() error {
+				return assignFloatingIP(networkingClient, fip, d.Id())
+			})
 			if err != nil {
-				fmt.Errorf("Error assigning floating IP to OpenStack compute instance: %s", err)
+				return fmt.Errorf("Error assigning floating IP to OpenStack compute instance: %s", err)
 			}
 		}
 	}
@@ -582,7 +948,7 @@ func resourceComputeInstanceV2Update(d *schema.ResourceData, meta interface{}) e
 			if blockClient, err := config.blockStorageV1Client(d.Get("region").(string)); err != nil {
 				return err
 			} else {
-				if err := detachVolumesFromInstance(computeClient, blockClient, d.Id(), oldAttachmentSet); err != nil {
+				if err := detachVolumesFromInstance(computeClient, blockClient, d.Id(), oldAttachmentSet, resourceInstanceTimeoutV2(d, "update")); err != nil {
 					return err
 				}
 			}
@@ -594,7 +960,7 @@ func resourceComputeInstanceV2Update(d *schema.ResourceData, meta interface{}) e
 			if blockClient, err := config.blockStorageV1Client(d.Get("region").(string)); err != nil {
 				return err
 			} else {
-				if err := attachVolumesToInstance(computeClient, blockClient, d.Id(), newAttachmentSet); err != nil {
+				if err := attachVolumesToInstance(computeClient, blockClient, d.Id(), newAttachmentSet, resourceInstanceTimeoutV2(d, "update")); err != nil {
 					return err
 				}
 			}
@@ -603,8 +969,15 @@ func resourceComputeInstanceV2Update(d *schema.ResourceData, meta interface{}) e
 		d.SetPartial("volume")
 	}
 
-	if d.HasChange("flavor_id") || d.HasChange("flavor_name") {
-		flavorId, err := getFlavorID(computeClient, d)
+	if d.HasChange("flavor_id") || d.HasChange("flavor_name") || d.HasChange("flavor_min_vcpus") ||
+		d.HasChange("flavor_min_ram") || d.HasChange("flavor_min_disk") || d.HasChange("flavor_properties") {
+		var flavorId string
+		var err error
+		if d.HasChange("flavor_id") || d.HasChange("flavor_name") {
+			flavorId, err = getFlavorID(computeClient, d)
+		} else {
+			flavorId, err = getFlavorIDByCapability(computeClient, d)
+		}
 		if err != nil {
 			return err
 		}
@@ -612,7 +985,9 @@ func resourceComputeInstanceV2Update(d *schema.ResourceData, meta interface{}) e
 			FlavorRef: flavorId,
 		}
 		log.Printf("[DEBUG] Resize configuration: %#v", resizeOpts)
-		err = servers.Resize(computeClient, d.Id(), resizeOpts).ExtractErr()
+		err = retryNovaCall(resourceInstanceTimeoutV2(d, "update"), func() error {
+			return servers.Resize(computeClient, d.Id(), resizeOpts).ExtractErr()
+		})
 		if err != nil {
 			return fmt.Errorf("Error resizing OpenStack server: %s", err)
 		}
@@ -656,9 +1031,118 @@ func resourceComputeInstanceV2Update(d *schema.ResourceData, meta interface{}) e
 		}
 	}
 
+	if d.HasChange("power_state") {
+		if err := resourceComputeInstanceV2SetPowerState(computeClient, d); err != nil {
+			return err
+		}
+	}
+
 	return resourceComputeInstanceV2Read(d, meta)
 }
 
+// powerStateFromServerStatus maps a Nova server status to the
+// power_state values accepted by this resource.
+func powerStateFromServerStatus(status string) string {
+	switch status {
+	case "ACTIVE":
+		return "active"
+	case "SHUTOFF":
+		return "shutoff"
+	case "PAUSED":
+		return "paused"
+	case "SUSPENDED":
+		return "suspended"
+	case "RESCUE":
+		return "rescue"
+	default:
+		return ""
+	}
+}
+
+// resourceComputeInstanceV2SetPowerState drives the instance to the
+// requested power_state via the matching Nova lifecycle extension and
+// waits for the corresponding server status.
+func resourceComputeInstanceV2SetPowerState(computeClient *gophercloud.ServiceClient, d *schema.ResourceData) error {
+	targetState := d.Get("power_state").(string)
+
+	var pending []string
+	var target string
+
+	switch targetState {
+	case "active":
+		// Nova's os-start only resumes from SHUTOFF; returning to active
+		// from paused/suspended/rescue needs the matching reverse action.
+		current, err := servers.Get(computeClient, d.Id()).Extract()
+		if err != nil {
+			return fmt.Errorf("Error getting OpenStack server (%s): %s", d.Id(), err)
+		}
+
+		switch current.Status {
+		case "PAUSED":
+			if err := pauseunpause.Unpause(computeClient, d.Id()).ExtractErr(); err != nil {
+				return fmt.Errorf("Error unpausing OpenStack server (%s): %s", d.Id(), err)
+			}
+			pending = []string{"PAUSED"}
+		case "SUSPENDED":
+			if err := suspendresume.Resume(computeClient, d.Id()).ExtractErr(); err != nil {
+				return fmt.Errorf("Error resuming OpenStack server (%s): %s", d.Id(), err)
+			}
+			pending = []string{"SUSPENDED"}
+		case "RESCUE":
+			if err := rescueunrescue.Unrescue(computeClient, d.Id()).ExtractErr(); err != nil {
+				return fmt.Errorf("Error unrescuing OpenStack server (%s): %s", d.Id(), err)
+			}
+			pending = []string{"RESCUE"}
+		default:
+			if err := startstop.Start(computeClient, d.Id()).ExtractErr(); err != nil {
+				return fmt.Errorf("Error starting OpenStack server (%s): %s", d.Id(), err)
+			}
+			pending = []string{"SHUTOFF"}
+		}
+		target = "ACTIVE"
+	case "shutoff":
+		if err := startstop.Stop(computeClient, d.Id()).ExtractErr(); err != nil {
+			return fmt.Errorf("Error stopping OpenStack server (%s): %s", d.Id(), err)
+		}
+		pending, target = []string{"ACTIVE"}, "SHUTOFF"
+	case "paused":
+		if err := pauseunpause.Pause(computeClient, d.Id()).ExtractErr(); err != nil {
+			return fmt.Errorf("Error pausing OpenStack server (%s): %s", d.Id(), err)
+		}
+		pending, target = []string{"ACTIVE"}, "PAUSED"
+	case "suspended":
+		if err := suspendresume.Suspend(computeClient, d.Id()).ExtractErr(); err != nil {
+			return fmt.Errorf("Error suspending OpenStack server (%s): %s", d.Id(), err)
+		}
+		pending, target = []string{"ACTIVE"}, "SUSPENDED"
+	case "rescue":
+		if err := rescueunrescue.Rescue(computeClient, d.Id()).ExtractErr(); err != nil {
+			return fmt.Errorf("Error rescuing OpenStack server (%s): %s", d.Id(), err)
+		}
+		pending, target = []string{"ACTIVE"}, "RESCUE"
+	default:
+		return fmt.Errorf("Invalid power_state %q for OpenStack server (%s)", targetState, d.Id())
+	}
+
+	log.Printf("[DEBUG] Waiting for instance (%s) to reach power_state %s", d.Id(), targetState)
+
+	stateConf := &resource.StateChangeConf{
+		Pending:    pending,
+		Target:     target,
+		Refresh:    ServerV2StateRefreshFunc(computeClient, d.Id()),
+		Timeout:    10 * time.Minute,
+		Delay:      10 * time.Second,
+		MinTimeout: 3 * time.Second,
+	}
+
+	_, err := stateConf.WaitForState()
+	if err != nil {
+		return fmt.Errorf("Error waiting for instance (%s) to reach power_state %s: %s", d.Id(), targetState, err)
+	}
+
+	return nil
+}
+
 func resourceComputeInstanceV2Delete(d *schema.ResourceData, meta interface{}) error {
 	config := meta.(*Config)
 	computeClient, err := config.computeV2Client(d.Get("region").(string))
@@ -666,7 +1150,38 @@ func resourceComputeInstanceV2Delete(d *schema.ResourceData, meta interface{}) e
 		return fmt.Errorf("Error creating OpenStack compute client: %s", err)
 	}
 
-	err = servers.Delete(computeClient, d.Id()).ExtractErr()
+	if d.Get("floating_ip_pool").(string) != "" {
+		if floatingIP := d.Get("floating_ip").(string); floatingIP != "" {
+			networkingClient, err := config.networkingV2Client(d.Get("region").(string))
+			if err != nil {
+				return fmt.Errorf("Error creating OpenStack compute client: %s", err)
+			}
+
+			allFloatingIPs, err := getFloatingIPs(networkingClient)
+			if err != nil {
+				return fmt.Errorf("Error listing OpenStack floating IPs: %s", err)
+			}
+			if fip := extractFloatingIPFromIP(allFloatingIPs, floatingIP); fip != nil {
+				if err := floatingips.Delete(networkingClient, fip.ID).ExtractErr(); err != nil {
+					return fmt.Errorf("Error releasing floating IP (%s) back to pool: %s", floatingIP, err)
+				}
+			}
+		}
+	}
+
+	if vols := d.Get("volume").(*schema.Set).List(); len(vols) > 0 {
+		blockClient, err := config.blockStorageV1Client(d.Get("region").(string))
+		if err != nil {
+			return fmt.Errorf("Error creating OpenStack block storage client: %s", err)
+		}
+		if err := detachVolumesFromInstance(computeClient, blockClient, d.Id(), vols, resourceInstanceTimeoutV2(d, "delete")); err != nil {
+			return err
+		}
+	}
+
+	err = retryNovaCall(resourceInstanceTimeoutV2(d, "delete"), func() error {
+		return servers.Delete(computeClient, d.Id()).ExtractErr()
+	})
 	if err != nil {
 		return fmt.Errorf("Error deleting OpenStack server: %s", err)
 	}
@@ -690,6 +1205,18 @@ func resourceComputeInstanceV2Delete(d *schema.ResourceData, meta interface{}) e
 			d.Id(), err)
 	}
 
+	if d.Get("use_blockstorage_volume").(bool) && !d.Get("delete_on_termination").(bool) {
+		if bootVolumeID := d.Get("boot_volume_id").(string); bootVolumeID != "" {
+			blockClient, err := config.blockStorageV1Client(d.Get("region").(string))
+			if err != nil {
+				return fmt.Errorf("Error creating OpenStack block storage client: %s", err)
+			}
+			if err := volumes.Delete(blockClient, bootVolumeID).ExtractErr(); err != nil {
+				return fmt.Errorf("Error deleting OpenStack root volume (%s): %s", bootVolumeID, err)
+			}
+		}
+	}
+
 	d.SetId("")
 	return nil
 }
@@ -723,6 +1250,50 @@ func resourceInstanceSecGroupsV2(d *schema.ResourceData) []string {
 	return secgroups
 }
 
+// assignFloatingIPsToNetworksV2 associates each network.floating_ip with
+// the port on its own network block, allowing a multi-NIC instance to
+// carry more than one floating IP.
+func assignFloatingIPsToNetworksV2(d *schema.ResourceData, config *Config, instanceID string) error {
+	rawNetworks := d.Get("network").([]interface{})
+	if len(rawNetworks) == 0 {
+		return nil
+	}
+
+	var networkingClient *gophercloud.ServiceClient
+	var allFloatingIPs []floatingips.FloatingIP
+
+	for _, raw := range rawNetworks {
+		rawMap := raw.(map[string]interface{})
+		floatingIP := rawMap["floating_ip"].(string)
+		if floatingIP == "" {
+			continue
+		}
+
+		if networkingClient == nil {
+			var err error
+			networkingClient, err = config.networkingV2Client(d.Get("region").(string))
+			if err != nil {
+				return fmt.Errorf("Error creating OpenStack compute client: %s", err)
+			}
+			allFloatingIPs, err = getFloatingIPs(networkingClient)
+			if err != nil {
+				return fmt.Errorf("Error listing OpenStack floating IPs: %s", err)
+			}
+		}
+
+		networkID := rawMap["uuid"].(string)
+		fip := extractFloatingIPFromIP(allFloatingIPs, floatingIP)
+		err := retryNovaCall(resourceInstanceTimeoutV2(d, "create"), func() error {
+			return assignFloatingIPToNetwork(networkingClient, fip, instanceID, networkID)
+		})
+		if err != nil {
+			return fmt.Errorf("Error assigning floating IP to OpenStack compute instance: %s", err)
+		}
+	}
+
+	return nil
+}
+
 func resourceInstanceNetworksV2(d *schema.ResourceData) []servers.Network {
 	rawNetworks := d.Get("network").([]interface{})
 	networks := make([]servers.Network, len(rawNetworks))
@@ -737,6 +1308,57 @@ func resourceInstanceNetworksV2(d *schema.ResourceData) []servers.Network {
 	return networks
 }
 
+// Nova's default quota for file injection: at most 5 files, each no
+// larger than 10KB once decoded.
+const (
+	maxPersonalityFiles   = 5
+	maxPersonalityContent = 10 * 1024
+)
+
+func resourceInstancePersonalityV2(d *schema.ResourceData) (servers.Personality, error) {
+	rawPersonality := d.Get("personality").([]interface{})
+	if len(rawPersonality) == 0 {
+		return nil, nil
+	}
+
+	if len(rawPersonality) > maxPersonalityFiles {
+		return nil, fmt.Errorf(
+			"Error creating OpenStack server: personality supports a maximum of %d files, got %d",
+			maxPersonalityFiles, len(rawPersonality))
+	}
+
+	personality := make(servers.Personality, len(rawPersonality))
+	for i, raw := range rawPersonality {
+		rawMap := raw.(map[string]interface{})
+		path := rawMap["path"].(string)
+		content := rawMap["content"].(string)
+
+		// The content may already be base64-encoded inline, or it may be
+		// a path to a local file to read and encode.
+		var decoded []byte
+		if d, err := base64.StdEncoding.DecodeString(content); err == nil {
+			decoded = d
+		} else if fileContent, err := ioutil.ReadFile(content); err == nil {
+			decoded = fileContent
+		} else {
+			return nil, fmt.Errorf("Error reading personality content for %s: %s", path, err)
+		}
+
+		if len(decoded) > maxPersonalityContent {
+			return nil, fmt.Errorf(
+				"Error creating OpenStack server: personality file %s is %d bytes, exceeds the %d byte limit",
+				path, len(decoded), maxPersonalityContent)
+		}
+
+		personality[i] = &servers.File{
+			Path:     path,
+			Contents: decoded,
+		}
+	}
+
+	return personality, nil
+}
+
 func resourceInstanceMetadataV2(d *schema.ResourceData) map[string]string {
 	m := make(map[string]string)
 	for key, val := range d.Get("metadata").(map[string]interface{}) {
@@ -760,6 +1382,74 @@ func resourceInstanceBlockDeviceV2(d *schema.ResourceData, bd map[string]interfa
 	return bfvOpts
 }
 
+func resourceInstanceSchedulerHintsV2(schedulerHintsRaw map[string]interface{}) schedulerhints.SchedulerHints {
+	differentHost := []string{}
+	if raw, ok := schedulerHintsRaw["different_host"].([]interface{}); ok {
+		for _, v := range raw {
+			differentHost = append(differentHost, v.(string))
+		}
+	}
+
+	sameHost := []string{}
+	if raw, ok := schedulerHintsRaw["same_host"].([]interface{}); ok {
+		for _, v := range raw {
+			sameHost = append(sameHost, v.(string))
+		}
+	}
+
+	query := []interface{}{}
+	if raw, ok := schedulerHintsRaw["query"].([]interface{}); ok {
+		for _, v := range raw {
+			query = append(query, v.(string))
+		}
+	}
+
+	schedulerHints := schedulerhints.SchedulerHints{
+		Group:           schedulerHintsRaw["group"].(string),
+		DifferentHost:   differentHost,
+		SameHost:        sameHost,
+		Query:           query,
+		TargetCell:      schedulerHintsRaw["target_cell"].(string),
+		BuildNearHostIP: schedulerHintsRaw["build_near_host_ip"].(string),
+		Cidr:            schedulerHintsRaw["cidr"].(string),
+	}
+
+	return schedulerHints
+}
+
+func resourceComputeSchedulerHintsHash(v interface{}) int {
+	var buf bytes.Buffer
+	m := v.(map[string]interface{})
+	if m["group"] != nil {
+		buf.WriteString(fmt.Sprintf("%s-", m["group"].(string)))
+	}
+	if m["target_cell"] != nil {
+		buf.WriteString(fmt.Sprintf("%s-", m["target_cell"].(string)))
+	}
+	if m["build_near_host_ip"] != nil {
+		buf.WriteString(fmt.Sprintf("%s-", m["build_near_host_ip"].(string)))
+	}
+	if m["cidr"] != nil {
+		buf.WriteString(fmt.Sprintf("%s-", m["cidr"].(string)))
+	}
+	if m["different_host"] != nil {
+		for _, hostId := range m["different_host"].([]interface{}) {
+			buf.WriteString(fmt.Sprintf("%s-", hostId.(string)))
+		}
+	}
+	if m["same_host"] != nil {
+		for _, hostId := range m["same_host"].([]interface{}) {
+			buf.WriteString(fmt.Sprintf("%s-", hostId.(string)))
+		}
+	}
+	if m["query"] != nil {
+		for _, q := range m["query"].([]interface{}) {
+			buf.WriteString(fmt.Sprintf("%s-", q.(string)))
+		}
+	}
+	return hashcode.String(buf.String())
+}
+
 func extractFloatingIPFromIP(ips []floatingips.FloatingIP, IP string) *floatingips.FloatingIP {
 	for _, floatingIP := range ips {
 		if floatingIP.FloatingIP == IP {
@@ -769,18 +1459,81 @@ func extractFloatingIPFromIP(ips []floatingips.FloatingIP, IP string) *floatingi
 	return nil
 }
 
+// assignFloatingIP associates floatingIP with the instance's port on the
+// first network in the networking service's network list; that network is
+// not checked against the instance's own networks. Kept for backward compatibility
+// with the deprecated top-level floating_ip attribute; prefer
+// assignFloatingIPToNetwork for instances with more than one network.
 func assignFloatingIP(networkingClient *gophercloud.ServiceClient, floatingIP *floatingips.FloatingIP, instanceID string) error {
 	networkID, err := getFirstNetworkID(networkingClient, instanceID)
 	if err != nil {
 		return err
 	}
+	return assignFloatingIPToNetwork(networkingClient, floatingIP, instanceID, networkID)
+}
+
+// assignFloatingIPToNetwork associates floatingIP with the port that
+// belongs to the given networkID, so multi-NIC instances get the IP on
+// the intended interface rather than whichever port is listed first.
+func assignFloatingIPToNetwork(networkingClient *gophercloud.ServiceClient, floatingIP *floatingips.FloatingIP, instanceID, networkID string) error {
 	portID, err := getInstancePortID(networkingClient, instanceID, networkID)
+	if err != nil {
+		return err
+	}
 	_, err = floatingips.Update(networkingClient, floatingIP.ID, floatingips.UpdateOpts{
 		PortID: portID,
 	}).Extract()
 	return err
 }
 
+// allocateFloatingIPFromPool allocates a new floating IP out of the named
+// pool (an external/floating network) so that callers don't have to
+// pre-provision one.
+func allocateFloatingIPFromPool(networkingClient *gophercloud.ServiceClient, pool string) (*floatingips.FloatingIP, error) {
+	poolID, err := getNetworkIDByName(networkingClient, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	floatingIP, err := floatingips.Create(networkingClient, floatingips.CreateOpts{
+		FloatingNetworkID: poolID,
+	}).Extract()
+	if err != nil {
+		return nil, err
+	}
+
+	return floatingIP, nil
+}
+
+// getNetworkIDByName resolves a floating IP pool name to the ID of the
+// network that backs it.
+func getNetworkIDByName(networkingClient *gophercloud.ServiceClient, name string) (string, error) {
+	pager := networks.List(networkingClient, networks.ListOpts{Name: name})
+
+	var networkID string
+	err := pager.EachPage(func(page pagination.Page) (bool, error) {
+		networkList, err := networks.ExtractNetworks(page)
+		if err != nil {
+			return false, err
+		}
+
+		for _, n := range networkList {
+			if n.Name == name {
+				networkID = n.ID
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if networkID == "" {
+		return "", fmt.Errorf("No network found matching floating IP pool %s", name)
+	}
+	return networkID, nil
+}
+
 func getFirstNetworkID(networkingClient *gophercloud.ServiceClient, instanceID string) (string, error) {
 	pager := networks.List(networkingClient, networks.ListOpts{})
 
@@ -850,6 +1603,44 @@ func getFloatingIPs(networkingClient *gophercloud.ServiceClient) ([]floatingips.
 	return ips, nil
 }
 
+// glanceImageNotFound is the sentinel value stored in image_name when the
+// instance's source image has since been deleted from Glance.
+const glanceImageNotFound = "Image not found"
+
+// maxGlanceRetries/glanceRetryDelay bound how long we keep retrying a
+// transient Glance error before giving up.
+const (
+	maxGlanceRetries = 10
+	glanceRetryDelay = 2 * time.Second
+)
+
+func isRetryableGlanceError(err error) bool {
+	errCode, ok := err.(*gophercloud.UnexpectedResponseCodeError)
+	if !ok {
+		return false
+	}
+	return errCode.Actual == 500 || errCode.Actual == 404
+}
+
+// retryGlanceCall calls f up to maxGlanceRetries times in all, sleeping
+// glanceRetryDelay after each failure, as long as it keeps failing with a
+// retryable (500/404) Glance error.
+func retryGlanceCall(f func() error) error {
+	var err error
+	for attempt := 0; attempt < maxGlanceRetries; attempt++ {
+		err = f()
+		if err == nil {
+			return nil
+		}
+		if !isRetryableGlanceError(err) {
+			return err
+		}
+		log.Printf("[DEBUG] Retryable error from Glance (attempt %d): %s", attempt+1, err)
+		time.Sleep(glanceRetryDelay)
+	}
+	return err
+}
+
 func getImageID(client *gophercloud.ServiceClient, d *schema.ResourceData) (string, error) {
 	imageId := d.Get("image_id").(string)
 
@@ -860,23 +1651,29 @@ func getImageID(client *gophercloud.ServiceClient, d *schema.ResourceData) (stri
 	imageCount := 0
 	imageName := d.Get("image_name").(string)
 	if imageName != "" {
-		pager := images.ListDetail(client, &images.ListOpts{
-			Name: imageName,
-		})
-		pager.EachPage(func(page pagination.Page) (bool, error) {
-			imageList, err := images.ExtractImages(page)
-			if err != nil {
-				return false, err
-			}
+		err := retryGlanceCall(func() error {
+			imageCount = 0
+			pager := images.ListDetail(client, &images.ListOpts{
+				Name: imageName,
+			})
+			return pager.EachPage(func(page pagination.Page) (bool, error) {
+				imageList, err := images.ExtractImages(page)
+				if err != nil {
+					return false, err
+				}
 
-			for _, i := range imageList {
-				if i.Name == imageName {
-					imageCount++
-					imageId = i.ID
+				for _, i := range imageList {
+					if i.Name == imageName {
+						imageCount++
+						imageId = i.ID
+					}
 				}
-			}
-			return true, nil
+				return true, nil
+			})
 		})
+		if err != nil {
+			return "", fmt.Errorf("Error listing images matching %s: %s", imageName, err)
+		}
 
 		switch imageCount {
 		case 0:
@@ -925,7 +1722,97 @@ func getFlavorID(client *gophercloud.ServiceClient, d *schema.ResourceData) (str
 			return "", fmt.Errorf("Found %d flavors matching %s", flavorCount, flavorName)
 		}
 	}
-	return "", fmt.Errorf("Neither a flavor ID nor a flavor name were able to be determined.")
+
+	return getFlavorIDByCapability(client, d)
+}
+
+// getFlavorIDByCapability resolves a flavor by required capabilities
+// (minimum vCPU/RAM/disk plus extra specs) rather than a hard-coded
+// name, so modules stay portable across clouds whose flavor names
+// differ.
+func getFlavorIDByCapability(client *gophercloud.ServiceClient, d *schema.ResourceData) (string, error) {
+	minVCPUs := d.Get("flavor_min_vcpus").(int)
+	minRAM := d.Get("flavor_min_ram").(int)
+	minDisk := d.Get("flavor_min_disk").(int)
+
+	wantProperties := map[string]string{}
+	for k, v := range d.Get("flavor_properties").(map[string]interface{}) {
+		wantProperties[k] = v.(string)
+	}
+
+	if minVCPUs == 0 && minRAM == 0 && minDisk == 0 && len(wantProperties) == 0 {
+		return "", fmt.Errorf("Neither a flavor ID nor a flavor name were able to be determined.")
+	}
+
+	var candidates []flavors.Flavor
+	inspected := []string{}
+
+	pager := flavors.ListDetail(client, nil)
+	err := pager.EachPage(func(page pagination.Page) (bool, error) {
+		flavorList, err := flavors.ExtractFlavors(page)
+		if err != nil {
+			return false, err
+		}
+
+		for _, f := range flavorList {
+			inspected = append(inspected, f.Name)
+
+			if f.VCPUs < minVCPUs || f.RAM < minRAM || f.Disk < minDisk {
+				continue
+			}
+
+			if len(wantProperties) > 0 {
+				extraSpecs, err := flavors.ListExtraSpecs(client, f.ID).Extract()
+				if err != nil {
+					return false, err
+				}
+				if !extraSpecsSatisfy(extraSpecs, wantProperties) {
+					continue
+				}
+			}
+
+			candidates = append(candidates, f)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(candidates) == 0 {
+		return "", fmt.Errorf(
+			"No flavor found matching min_vcpus=%d, min_ram=%d, min_disk=%d, properties=%v; inspected: %v",
+			minVCPUs, minRAM, minDisk, wantProperties, inspected)
+	}
+
+	sort.Sort(byFlavorSize(candidates))
+
+	return candidates[0].ID, nil
+}
+
+func extraSpecsSatisfy(have map[string]string, want map[string]string) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// byFlavorSize sorts flavors by (vcpus, ram, disk) ascending so the
+// cheapest candidate that still meets the minimums is picked first.
+type byFlavorSize []flavors.Flavor
+
+func (s byFlavorSize) Len() int      { return len(s) }
+func (s byFlavorSize) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byFlavorSize) Less(i, j int) bool {
+	if s[i].VCPUs != s[j].VCPUs {
+		return s[i].VCPUs < s[j].VCPUs
+	}
+	if s[i].RAM != s[j].RAM {
+		return s[i].RAM < s[j].RAM
+	}
+	return s[i].Disk < s[j].Disk
 }
 
 func resourceComputeVolumeAttachmentHash(v interface{}) int {
@@ -936,7 +1823,116 @@ func resourceComputeVolumeAttachmentHash(v interface{}) int {
 	return hashcode.String(buf.String())
 }
 
-func attachVolumesToInstance(computeClient *gophercloud.ServiceClient, blockClient *gophercloud.ServiceClient, serverId string, vols []interface{}) error {
+// pickAvailabilityZone intersects the user's ordered zones list with what
+// compute (and, when booting from a volume, block storage) currently
+// report as available, and returns the first viable one. This avoids
+// manual retries when a preferred AZ is temporarily out of capacity.
+func pickAvailabilityZone(computeClient *gophercloud.ServiceClient, blockClient *gophercloud.ServiceClient, zones []string, needsVolume bool) (string, error) {
+	computeZones, err := listAvailableZones(computeClient)
+	if err != nil {
+		return "", fmt.Errorf("Error listing compute availability zones: %s", err)
+	}
+
+	viable := computeZones
+	if needsVolume {
+		blockZones, err := listAvailableZones(blockClient)
+		if err != nil {
+			return "", fmt.Errorf("Error listing block storage availability zones: %s", err)
+		}
+		viable = intersectZones(viable, blockZones)
+	}
+
+	for _, zone := range zones {
+		if viable[zone] {
+			return zone, nil
+		}
+	}
+
+	return "", fmt.Errorf("None of the requested availability zones %v are currently available (found: %v)", zones, viable)
+}
+
+// listAvailableZones returns the set of zone names that are reported as
+// available on the given service client (compute or block storage; both
+// expose the same os-availability-zone extension).
+func listAvailableZones(client *gophercloud.ServiceClient) (map[string]bool, error) {
+	available := map[string]bool{}
+	if client == nil {
+		return available, nil
+	}
+
+	pages, err := availabilityzones.List(client).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	zoneList, err := availabilityzones.ExtractAvailabilityZones(pages)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, z := range zoneList {
+		if z.ZoneState.Available {
+			available[z.ZoneName] = true
+		}
+	}
+
+	return available, nil
+}
+
+func intersectZones(a, b map[string]bool) map[string]bool {
+	out := map[string]bool{}
+	for zone := range a {
+		if b[zone] {
+			out[zone] = true
+		}
+	}
+	return out
+}
+
+// resourceInstanceBootVolumeV2 creates a Cinder volume from the instance's
+// image and waits for it to become available, so the instance can boot
+// from a persistent root disk instead of ephemeral storage.
+func resourceInstanceBootVolumeV2(blockClient *gophercloud.ServiceClient, d *schema.ResourceData, imageId, availabilityZone string) (*volumes.Volume, error) {
+	name := d.Get("volume_name").(string)
+	if name == "" {
+		name = fmt.Sprintf("%s-volume", d.Get("name").(string))
+	}
+
+	if availabilityZone == "" {
+		availabilityZone = d.Get("volume_availability_zone").(string)
+	}
+
+	createOpts := &volumes.CreateOpts{
+		Size:             d.Get("volume_size").(int),
+		Name:             name,
+		VolumeType:       d.Get("volume_type").(string),
+		AvailabilityZone: availabilityZone,
+		ImageID:          imageId,
+	}
+
+	log.Printf("[DEBUG] Boot volume create options: %#v", createOpts)
+	volume, err := volumes.Create(blockClient, createOpts).Extract()
+	if err != nil {
+		return nil, err
+	}
+
+	stateConf := &resource.StateChangeConf{
+		Pending:    []string{"creating", "downloading"},
+		Target:     "available",
+		Refresh:    VolumeV1StateRefreshFunc(blockClient, volume.ID),
+		Timeout:    30 * time.Minute,
+		Delay:      5 * time.Second,
+		MinTimeout: 2 * time.Second,
+	}
+
+	if _, err := stateConf.WaitForState(); err != nil {
+		return nil, err
+	}
+
+	return volume, nil
+}
+
+func attachVolumesToInstance(computeClient *gophercloud.ServiceClient, blockClient *gophercloud.ServiceClient, serverId string, vols []interface{}, timeout time.Duration) error {
 	if len(vols) > 0 {
 		for _, v := range vols {
 			va := v.(map[string]interface{})
@@ -957,7 +1953,11 @@ func attachVolumesToInstance(computeClient *gophercloud.ServiceClient, blockClie
 				VolumeID: volumeId,
 			}
 
-			if _, err := volumeattach.Create(computeClient, s, vaOpts).Extract(); err != nil {
+			err := retryNovaCall(timeout, func() error {
+				_, err := volumeattach.Create(computeClient, s, vaOpts).Extract()
+				return err
+			})
+			if err != nil {
 				return err
 			}
 
@@ -980,13 +1980,68 @@ func attachVolumesToInstance(computeClient *gophercloud.ServiceClient, blockClie
 	return nil
 }
 
-func detachVolumesFromInstance(computeClient *gophercloud.ServiceClient, blockClient *gophercloud.ServiceClient, serverId string, vols []interface{}) error {
+// SnapshotV1StateRefreshFunc returns a resource.StateRefreshFunc that is
+// used to watch a Cinder snapshot, mirroring VolumeV1StateRefreshFunc.
+func SnapshotV1StateRefreshFunc(client *gophercloud.ServiceClient, snapshotId string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		s, err := snapshots.Get(client, snapshotId).Extract()
+		if err != nil {
+			errCode, ok := err.(*gophercloud.UnexpectedResponseCodeError)
+			if ok && errCode.Actual == 404 {
+				return s, "deleted", nil
+			}
+			return nil, "", err
+		}
+
+		return s, s.Status, nil
+	}
+}
+
+// snapshotVolumeBeforeDetach takes a Cinder snapshot of volumeId and waits
+// for it to become available, so destroying an instance doesn't silently
+// lose the data on an attached volume. Errors here abort the destroy.
+func snapshotVolumeBeforeDetach(blockClient *gophercloud.ServiceClient, volumeId string) error {
+	name := fmt.Sprintf("%s-snapshot-%d", volumeId, hashcode.String(volumeId))
+
+	snapshot, err := snapshots.Create(blockClient, snapshots.CreateOpts{
+		VolumeID: volumeId,
+		Name:     name,
+		Force:    true,
+	}).Extract()
+	if err != nil {
+		return err
+	}
+
+	stateConf := &resource.StateChangeConf{
+		Pending:    []string{"creating"},
+		Target:     "available",
+		Refresh:    SnapshotV1StateRefreshFunc(blockClient, snapshot.ID),
+		Timeout:    30 * time.Minute,
+		Delay:      5 * time.Second,
+		MinTimeout: 2 * time.Second,
+	}
+
+	_, err = stateConf.WaitForState()
+	return err
+}
+
+func detachVolumesFromInstance(computeClient *gophercloud.ServiceClient, blockClient *gophercloud.ServiceClient, serverId string, vols []interface{}, timeout time.Duration) error {
 	if len(vols) > 0 {
 		for _, v := range vols {
 			va := v.(map[string]interface{})
 			aId := va["id"].(string)
+			volumeId := va["volume_id"].(string)
 
-			if err := volumeattach.Delete(computeClient, serverId, aId).ExtractErr(); err != nil {
+			if snapshotBeforeDetach, ok := va["snapshot_before_detach"].(bool); ok && snapshotBeforeDetach {
+				if err := snapshotVolumeBeforeDetach(blockClient, volumeId); err != nil {
+					return fmt.Errorf("Error snapshotting volume (%s) before detach: %s", volumeId, err)
+				}
+			}
+
+			err := retryNovaCall(timeout, func() error {
+				return volumeattach.Delete(computeClient, serverId, aId).ExtractErr()
+			})
+			if err != nil {
 				return err
 			}
 